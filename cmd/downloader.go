@@ -0,0 +1,268 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	downloadMaxRetries = 5
+	downloadBaseDelay  = 500 * time.Millisecond
+)
+
+// downloadTimeout bounds each individual download attempt (not the overall
+// retry loop). It's configurable via initCmd's --download-timeout flag.
+var downloadTimeout = 10 * time.Minute
+
+// downloadClient is the http.Client used for all template/asset downloads.
+// It allows file:// URLs so that `appsody init` keeps working against
+// local test fixtures. Per-attempt timeouts are applied via request
+// context rather than Client.Timeout, since downloadTimeout can change
+// between attempts (e.g. in tests) and downloadAll shares this client
+// across concurrent downloads.
+var downloadClient = &http.Client{
+	Transport: func() http.RoundTripper {
+		t := &http.Transport{}
+		t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+		return t
+	}(),
+}
+
+// downloadFile fetches url to destFile, resuming a partial download left
+// behind by a previous attempt and retrying transient failures with
+// exponential backoff. Progress is reported through the Info logger.
+func downloadFile(url string, destFile string) error {
+	if dryrun {
+		Info.logf("Dry Run -Skipping download of url: %s to destination %s", url, destFile)
+		return nil
+	}
+
+	partFile := destFile + ".part"
+
+	var lastErr error
+	for attempt := 0; attempt < downloadMaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := downloadBaseDelay * time.Duration(1<<uint(attempt-1))
+			Warning.logf("Download of %s failed: %v. Retrying in %v (attempt %d/%d)", url, lastErr, delay, attempt+1, downloadMaxRetries)
+			time.Sleep(delay)
+		}
+
+		err := downloadAttempt(url, partFile)
+		if err == nil {
+			os.Remove(partFile + ".etag")
+			return os.Rename(partFile, destFile)
+		}
+		lastErr = err
+		if !isRetryableDownloadError(err) {
+			break
+		}
+	}
+
+	return fmt.Errorf("failed to download %s: %v", url, lastErr)
+}
+
+// downloadRetryableError wraps an error that downloadAttempt considers worth
+// retrying (a network error or a 5xx response).
+type downloadRetryableError struct {
+	err error
+}
+
+func (e *downloadRetryableError) Error() string { return e.err.Error() }
+
+func isRetryableDownloadError(err error) bool {
+	_, ok := err.(*downloadRetryableError)
+	return ok
+}
+
+// downloadAttempt performs a single download attempt of url into partFile,
+// resuming from partFile's current size via an HTTP Range request when
+// possible. Resume is only attempted when we have the ETag the partial
+// content was downloaded against, sent back as If-Range: a server that
+// still has that exact representation honors the Range and returns 206;
+// one that doesn't (the file was re-published, a different CDN node has a
+// different version, ...) returns the full 200 response instead of
+// silently serving bytes that don't belong after our existing partial
+// content. Without a stored ETag to send, we don't risk it - we discard
+// the partial and restart from scratch.
+func downloadAttempt(url string, partFile string) error {
+	etagFile := partFile + ".etag"
+
+	var startOffset int64
+	var etag string
+	if info, err := os.Stat(partFile); err == nil {
+		if savedEtag, err := ioutil.ReadFile(etagFile); err == nil && len(savedEtag) > 0 {
+			startOffset = info.Size()
+			etag = string(savedEtag)
+		} else {
+			// No ETag recorded for this partial download - we can't safely
+			// verify the remote content hasn't changed, so start over.
+			os.Remove(partFile)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if startOffset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", startOffset))
+		req.Header.Set("If-Range", etag)
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return &downloadRetryableError{err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Either a fresh download, or the server ignored/invalidated our
+		// If-Range - either way the body is the full, current content.
+		startOffset = 0
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	case http.StatusPartialContent:
+		// resuming as requested, against the representation we recorded
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the server doesn't recognize our offset (e.g. it changed) - discard
+		// the partial download so the retry restarts from scratch
+		os.Remove(partFile)
+		os.Remove(etagFile)
+		return &downloadRetryableError{fmt.Errorf("%s", resp.Status)}
+	default:
+		if resp.StatusCode >= 500 {
+			return &downloadRetryableError{fmt.Errorf("%s", resp.Status)}
+		}
+		return fmt.Errorf("failed to fetch %s : %s", url, resp.Status)
+	}
+
+	if newEtag := resp.Header.Get("ETag"); newEtag != "" {
+		if err := ioutil.WriteFile(etagFile, []byte(newEtag), 0644); err != nil {
+			Warning.log("Unable to record ETag for ", url, ": ", err)
+		}
+	} else {
+		os.Remove(etagFile)
+	}
+
+	outFile, err := os.OpenFile(partFile, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	total := startOffset + resp.ContentLength
+	progress := newDownloadProgress(url, total, startOffset)
+	_, err = io.Copy(outFile, io.TeeReader(resp.Body, progress))
+	if err != nil {
+		return &downloadRetryableError{err}
+	}
+	progress.done()
+	return nil
+}
+
+// downloadProgress reports download progress to the Info logger at most
+// once a second, to avoid flooding the console.
+type downloadProgress struct {
+	url        string
+	total      int64
+	downloaded int64
+	lastReport time.Time
+}
+
+func newDownloadProgress(url string, total int64, startOffset int64) *downloadProgress {
+	return &downloadProgress{url: url, total: total, downloaded: startOffset}
+}
+
+func (p *downloadProgress) Write(b []byte) (int, error) {
+	n := len(b)
+	p.downloaded += int64(n)
+	if time.Since(p.lastReport) > time.Second {
+		p.report()
+		p.lastReport = time.Now()
+	}
+	return n, nil
+}
+
+func (p *downloadProgress) report() {
+	if p.total > 0 {
+		Info.logf("Downloading %s: %d%% (%d/%d bytes)", p.url, p.downloaded*100/p.total, p.downloaded, p.total)
+	} else {
+		Info.logf("Downloading %s: %d bytes", p.url, p.downloaded)
+	}
+}
+
+func (p *downloadProgress) done() {
+	p.report()
+}
+
+// downloadJob is one URL/destination pair for downloadAll.
+type downloadJob struct {
+	URL  string
+	Dest string
+}
+
+// downloadAll runs downloadFile for each job using a bounded pool of
+// concurrency workers, returning one error per job (nil on success) in the
+// same order as jobs. This lets callers like `appsody list --prefetch` or a
+// multi-stack test harness fetch several templates in parallel without each
+// caller having to manage its own worker pool.
+func downloadAll(jobs []downloadJob, concurrency int) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(jobs))
+	jobCh := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobCh {
+				errs[i] = downloadFile(jobs[i].URL, jobs[i].Dest)
+			}
+		}()
+	}
+
+	for i := range jobs {
+		jobCh <- i
+	}
+	close(jobCh)
+	wg.Wait()
+
+	return errs
+}
+
+func init() {
+	initCmd.PersistentFlags().DurationVar(&downloadTimeout, "download-timeout", downloadTimeout, "Timeout for each template download attempt.")
+}
@@ -0,0 +1,110 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSourceFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDownloadAllPreservesOrderAndReportsPerJobErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appsody-downloadall-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src1 := writeSourceFile(t, dir, "one.txt", "one")
+	src3 := writeSourceFile(t, dir, "three.txt", "three")
+	missing := filepath.Join(dir, "does-not-exist.txt")
+
+	dest1 := filepath.Join(dir, "dest-one.txt")
+	dest2 := filepath.Join(dir, "dest-two.txt")
+	dest3 := filepath.Join(dir, "dest-three.txt")
+
+	jobs := []downloadJob{
+		{URL: "file://" + src1, Dest: dest1},
+		{URL: "file://" + missing, Dest: dest2},
+		{URL: "file://" + src3, Dest: dest3},
+	}
+
+	errs := downloadAll(jobs, 2)
+
+	if len(errs) != len(jobs) {
+		t.Fatalf("expected %d results, got %d", len(jobs), len(errs))
+	}
+	if errs[0] != nil {
+		t.Errorf("job 0 (%s) should have succeeded, got: %v", jobs[0].URL, errs[0])
+	}
+	if errs[1] == nil {
+		t.Errorf("job 1 (%s) should have failed, it points at a file that doesn't exist", jobs[1].URL)
+	}
+	if errs[2] != nil {
+		t.Errorf("job 2 (%s) should have succeeded, got: %v", jobs[2].URL, errs[2])
+	}
+
+	got1, err := ioutil.ReadFile(dest1)
+	if err != nil || string(got1) != "one" {
+		t.Errorf("dest1 = %q, %v; want %q, nil", got1, err, "one")
+	}
+	got3, err := ioutil.ReadFile(dest3)
+	if err != nil || string(got3) != "three" {
+		t.Errorf("dest3 = %q, %v; want %q, nil", got3, err, "three")
+	}
+	if _, err := os.Stat(dest2); !os.IsNotExist(err) {
+		t.Errorf("dest2 should not have been created for a failed download")
+	}
+}
+
+func TestDownloadAllHandlesMoreJobsThanWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "appsody-downloadall-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const numJobs = 6
+	jobs := make([]downloadJob, numJobs)
+	for i := 0; i < numJobs; i++ {
+		src := writeSourceFile(t, dir, fmt.Sprintf("src-%d.txt", i), fmt.Sprintf("content-%d", i))
+		jobs[i] = downloadJob{URL: "file://" + src, Dest: filepath.Join(dir, fmt.Sprintf("dest-%d.txt", i))}
+	}
+
+	errs := downloadAll(jobs, 2)
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("job %d failed: %v", i, err)
+			continue
+		}
+		got, readErr := ioutil.ReadFile(jobs[i].Dest)
+		want := fmt.Sprintf("content-%d", i)
+		if readErr != nil || string(got) != want {
+			t.Errorf("dest %d = %q, %v; want %q, nil", i, got, readErr, want)
+		}
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -32,12 +31,18 @@ import (
 )
 
 var (
-	overwrite  bool
-	noTemplate bool
+	overwrite      bool
+	noTemplate     bool
+	excludePattern string
+	allowSymlinks  bool
 )
 var whiteListDotDirectories = []string{"github", "vscode", "settings", "metadata"}
 var whiteListDotFiles = []string{"git", "project", "DS_Store", "classpath", "factorypath", "gitattributes", "gitignore", "cw-settings", "cw-extension"}
 
+// defaultExclusionPattern mirrors s2i's DefaultExclusionPattern - by default
+// we strip SCM metadata out of extracted templates.
+const defaultExclusionPattern = `(^|/)\.git(/|$)`
+
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init [stack]",
@@ -54,8 +59,6 @@ setup the local dev environment.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var index RepoIndex
 
-		var proceedWithTemplate bool
-
 		err := CheckPrereqs()
 		if err != nil {
 			Warning.logf("Failed to check prerequisites: %v\n", err)
@@ -74,72 +77,73 @@ setup the local dev environment.`,
 			Error.logf("Could not find a stack with the name %s. Run `appsody list` to see the available stacks or -h for help.", projectType)
 			os.Exit(1)
 		}
-		var projectName = index.Projects[projectType][0].URLs[0]
 
-		Info.log("Running appsody init...")
-
-		// 1. Check for empty directory
-		dir, err := os.Getwd()
-		if err != nil {
-			Error.log("Error getting current directory ", err)
+		if err := initProject(&index, projectType); err != nil {
+			Error.log(err)
 			os.Exit(1)
 		}
-		appsodyConfigFile := filepath.Join(dir, ".appsody-config.yaml")
 
-		_, err = os.Stat(appsodyConfigFile)
-		if err == nil {
-			Error.log("Cannot run appsody init <stack> on an existing appsody project.")
-			os.Exit(1)
-		}
+		install()
+	},
+}
 
-		if noTemplate || overwrite {
-			proceedWithTemplate = true
-		} else {
-			proceedWithTemplate = isFileLaydownSafe(dir)
-		}
-		// Download and untar
+// initProject downloads and extracts the template project for projectType
+// into the current directory. It is shared by `appsody init <stack>` and
+// `appsody new`.
+func initProject(index *RepoIndex, projectType string) error {
+	var proceedWithTemplate bool
 
-		if !overwrite && !proceedWithTemplate {
-			Error.log("Local files exist which may conflict with the template project. If you wish to proceed, try again with the --overwrite option.")
-			os.Exit(1)
-		}
+	var projectName = index.Projects[projectType][0].URLs[0]
 
-		Info.logf("Downloading %s template project from %s", projectType, projectName)
-		filename := projectType + ".tar.gz"
+	Info.log("Running appsody init...")
 
-		err = downloadFile(projectName, filename)
-		if err != nil {
-			Error.log("Error downloading tar ", err)
-			os.Exit(1)
-		}
-		Info.log("Download complete. Extracting files from ", filename)
-		//if noTemplate
-		errUntar := untar(filename, noTemplate)
+	// 1. Check for empty directory
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("Error getting current directory %v", err)
+	}
+	appsodyConfigFile := filepath.Join(dir, ".appsody-config.yaml")
 
-		if dryrun {
-			Info.logf("Dry Run - Skipping remove of temporary file for project type: %s project name: %s", projectType, projectName)
-		} else {
-			err = os.Remove(filename)
-			if err != nil {
-				Warning.log("Unable to remove temporary file ", filename)
-			}
-			Info.log("Successfully initialized ", projectType, " project")
-		}
-		if errUntar != nil {
-			Error.log("Error extracting template: ", errUntar)
-			// this leave the tar file in the dir
-			os.Exit(1)
-		}
+	_, err = os.Stat(appsodyConfigFile)
+	if err == nil {
+		return errors.New("Cannot run appsody init <stack> on an existing appsody project.")
+	}
 
-		install()
-	},
+	if noTemplate || overwrite {
+		proceedWithTemplate = true
+	} else {
+		proceedWithTemplate = isFileLaydownSafe(dir)
+	}
+	// Download and untar
+
+	if !overwrite && !proceedWithTemplate {
+		return errors.New("Local files exist which may conflict with the template project. If you wish to proceed, try again with the --overwrite option.")
+	}
+
+	downloader := DownloaderForSource(projectName)
+	if err := downloader.Fetch(index, projectType, projectName); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(initCmd)
 	initCmd.PersistentFlags().BoolVar(&overwrite, "overwrite", false, "Download and extract the template project, overwriting existing files.")
 	initCmd.PersistentFlags().BoolVar(&noTemplate, "no-template", false, "Only create the .appsody-config.yaml file. Do not unzip the template project.")
+	initCmd.PersistentFlags().StringVar(&excludePattern, "exclude", defaultExclusionPattern, "A regular expression matched against each entry in the template tarball. Matching entries are not extracted.")
+	initCmd.PersistentFlags().BoolVar(&allowSymlinks, "allow-symlinks", false, "Allow symlinks and hard links in the template tarball. By default they are rejected.")
+
+}
 
+// compileExcludePattern compiles pattern into a *regexp.Regexp, returning nil
+// (meaning "exclude nothing") when pattern is empty.
+func compileExcludePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
 }
 
 //Runs the .appsody-init.sh/bat files if necessary
@@ -157,51 +161,23 @@ func install() {
 
 }
 
-func downloadFile(url string, destFile string) error {
-	if dryrun {
-		Info.logf("Dry Run -Skipping download of url: %s to destination %s", url, destFile)
-
-	} else {
-		outFile, err := os.Create(destFile)
-		if err != nil {
-			return err
-		}
-		defer outFile.Close()
-
-		// allow file:// scheme
-		t := &http.Transport{}
-		t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
-
-		httpClient := &http.Client{Transport: t}
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return err
-		}
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return fmt.Errorf("Failed to fetch %s : %s", url, resp.Status)
-		}
-
-		_, err = io.Copy(outFile, resp.Body)
-		if err != nil {
-			return err
-		}
-		resp.Body.Close()
-	}
-	return nil
-}
+// maxTarFileSize and maxTarArchiveSize bound how much data untar will ever
+// write to disk from a single template tarball, as a defense against
+// decompression bombs in gzip'd template archives. These are vars rather
+// than consts so tests can shrink them instead of constructing
+// multi-hundred-megabyte fixtures.
+var (
+	maxTarFileSize    int64 = 200 * 1024 * 1024  // 200MiB for any single file
+	maxTarArchiveSize int64 = 1024 * 1024 * 1024 // 1GiB total across the archive
+)
 
-func untar(file string, noTemplate bool) error {
+func untar(file string, noTemplate bool, exclude *regexp.Regexp) error {
 
 	if dryrun {
 		Info.log("Dry Run - Skipping untar of file:  ", file)
 	} else {
 		if !overwrite && !noTemplate {
-			err := preCheckTar(file)
+			err := preCheckTar(file, exclude)
 			if err != nil {
 				return err
 			}
@@ -218,6 +194,14 @@ func untar(file string, noTemplate bool) error {
 		}
 		defer gzipReader.Close()
 		tarReader := tar.NewReader(gzipReader)
+
+		destDir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		var totalSize int64
+
 		for {
 			header, err := tarReader.Next()
 
@@ -230,29 +214,69 @@ func untar(file string, noTemplate bool) error {
 				continue
 			}
 
-			filename := header.Name
+			if exclude != nil && exclude.MatchString(header.Name) {
+				Debug.log("Untar skipping excluded entry ", header.Name)
+				continue
+			}
+
+			filename, err := safeExtractPath(destDir, header.Name)
+			if err != nil {
+				return fmt.Errorf("refusing to extract %s: %v", header.Name, err)
+			}
 			Debug.log("Untar creating ", filename)
 
-			if header.Typeflag == tar.TypeDir && !noTemplate {
-				if _, err := os.Stat(filename); err != nil {
-					err := os.MkdirAll(filename, 0755)
-					if err != nil {
-						return err
+			if header.Size > maxTarFileSize {
+				return fmt.Errorf("entry %s is %d bytes, which exceeds the %d byte per-file limit", header.Name, header.Size, maxTarFileSize)
+			}
+			totalSize += header.Size
+			if totalSize > maxTarArchiveSize {
+				return fmt.Errorf("archive %s exceeds the %d byte total size limit", file, maxTarArchiveSize)
+			}
+
+			switch header.Typeflag {
+			case tar.TypeDir:
+				if !noTemplate {
+					if _, err := os.Stat(filename); err != nil {
+						err := os.MkdirAll(filename, 0755)
+						if err != nil {
+							return err
+						}
 					}
 				}
-			} else if header.Typeflag == tar.TypeReg {
+			case tar.TypeReg:
 				if !noTemplate || (noTemplate && strings.HasSuffix(filename, ".appsody-config.yaml")) {
 
+					if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+						return err
+					}
 					f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
 					if err != nil {
 						return err
 					}
-					_, err = io.Copy(f, tarReader)
-					if err != nil {
+					_, err = io.CopyN(f, tarReader, header.Size)
+					if err != nil && err != io.EOF {
+						f.Close()
 						return err
 					}
 					f.Close()
 				}
+			case tar.TypeSymlink, tar.TypeLink:
+				if !allowSymlinks {
+					return fmt.Errorf("entry %s is a link and --allow-symlinks was not specified", header.Name)
+				}
+				if !noTemplate {
+					if _, err := safeExtractPath(destDir, header.Linkname); err != nil {
+						return fmt.Errorf("refusing to extract link %s: target %s escapes the destination directory", header.Name, header.Linkname)
+					}
+					os.Remove(filename)
+					if header.Typeflag == tar.TypeSymlink {
+						if err := os.Symlink(header.Linkname, filename); err != nil {
+							return err
+						}
+					} else if err := os.Link(filepath.Join(destDir, header.Linkname), filename); err != nil {
+						return err
+					}
+				}
 			}
 
 		}
@@ -260,6 +284,29 @@ func untar(file string, noTemplate bool) error {
 	return nil
 }
 
+// safeExtractPath joins destDir and name, and verifies that the resulting
+// path is still contained within destDir. This guards against tar entries
+// using ".." path segments, absolute paths, or (on Windows) drive letters
+// and UNC prefixes to escape the extraction directory.
+func safeExtractPath(destDir string, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, `\\`) {
+		return "", fmt.Errorf("entry path %q must be relative", name)
+	}
+
+	cleanDestDir := filepath.Clean(destDir)
+	target := filepath.Join(cleanDestDir, name)
+
+	rel, err := filepath.Rel(cleanDestDir, target)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("entry path %q escapes the destination directory", name)
+	}
+
+	return target, nil
+}
+
 func isFileLaydownSafe(directory string) bool {
 
 	safe := true
@@ -306,7 +353,7 @@ func inWhiteList(filename string) bool {
 	return isWhiteListed
 }
 
-func preCheckTar(file string) error {
+func preCheckTar(file string, exclude *regexp.Regexp) error {
 	preCheckOK := true
 	fileReader, err := os.Open(file)
 	if err != nil {
@@ -333,6 +380,8 @@ func preCheckTar(file string) error {
 		}
 		if header == nil {
 			continue
+		} else if exclude != nil && exclude.MatchString(header.Name) {
+			continue
 		} else {
 
 			if inWhiteList(header.Name) {
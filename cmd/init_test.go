@@ -0,0 +1,225 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeExtractPath(t *testing.T) {
+	destDir := string(filepath.Separator) + filepath.Join("tmp", "appsody-dest")
+
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr bool
+	}{
+		{"plain relative file", "pom.xml", false},
+		{"nested relative file", filepath.Join("src", "main", "App.java"), false},
+		{"dot-dot escape", filepath.Join("..", "..", "etc", "passwd"), true},
+		{"leading dot-dot escape", "../escaped.txt", true},
+		{"absolute path", string(filepath.Separator) + filepath.Join("etc", "passwd"), true},
+		{"windows UNC-ish path", `\\evil\share\file.txt`, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := safeExtractPath(destDir, tc.entry)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("safeExtractPath(%q) = %q, want error", tc.entry, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("safeExtractPath(%q) returned unexpected error: %v", tc.entry, err)
+			}
+			rel, relErr := filepath.Rel(destDir, got)
+			if relErr != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				t.Fatalf("safeExtractPath(%q) = %q escapes destDir %q", tc.entry, got, destDir)
+			}
+		})
+	}
+}
+
+type tarEntry struct {
+	name         string
+	typeflag     byte
+	declaredSize int64
+	content      []byte
+	linkname     string
+}
+
+// writeTestTarGz builds a tar.gz at path containing entries. Each entry's
+// header size is declaredSize, or len(content) if that is larger, and its
+// content is zero-padded up to that size so the archive stays well-formed
+// while still exercising untar's size-cap checks on header.Size.
+func writeTestTarGz(t *testing.T, path string, entries []tarEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	for _, e := range entries {
+		content := e.content
+		if pad := e.declaredSize - int64(len(content)); pad > 0 {
+			content = append(append([]byte{}, content...), make([]byte, pad)...)
+		}
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Linkname: e.linkname,
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if len(content) > 0 {
+			if _, err := tw.Write(content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func withTempWorkdir(t *testing.T) (cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "appsody-untar-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.Chdir(origWd)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUntarRejectsPathTraversal(t *testing.T) {
+	cleanup := withTempWorkdir(t)
+	defer cleanup()
+
+	writeTestTarGz(t, "evil.tar.gz", []tarEntry{
+		{name: "../../../../tmp/appsody-pwned", content: []byte("pwned")},
+	})
+
+	if err := untar("evil.tar.gz", false, nil); err == nil {
+		t.Fatal("expected untar to reject a tar entry that escapes the destination directory")
+	}
+	if _, err := os.Stat("/tmp/appsody-pwned"); err == nil {
+		os.Remove("/tmp/appsody-pwned")
+		t.Fatal("untar wrote outside the destination directory")
+	}
+}
+
+func TestUntarRejectsSymlinksByDefault(t *testing.T) {
+	cleanup := withTempWorkdir(t)
+	defer cleanup()
+
+	origAllowSymlinks := allowSymlinks
+	allowSymlinks = false
+	defer func() { allowSymlinks = origAllowSymlinks }()
+
+	writeTestTarGz(t, "evil.tar.gz", []tarEntry{
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+	})
+
+	if err := untar("evil.tar.gz", false, nil); err == nil {
+		t.Fatal("expected untar to reject a symlink entry without --allow-symlinks")
+	}
+}
+
+func TestUntarRejectsSymlinkEscapeEvenWhenAllowed(t *testing.T) {
+	cleanup := withTempWorkdir(t)
+	defer cleanup()
+
+	origAllowSymlinks := allowSymlinks
+	allowSymlinks = true
+	defer func() { allowSymlinks = origAllowSymlinks }()
+
+	writeTestTarGz(t, "evil.tar.gz", []tarEntry{
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "../../../../etc/passwd"},
+	})
+
+	if err := untar("evil.tar.gz", false, nil); err == nil {
+		t.Fatal("expected untar to reject a symlink target that escapes the destination directory")
+	}
+}
+
+// withShrunkTarLimits temporarily lowers the per-file/per-archive size caps
+// so tests can exercise them with tiny fixtures instead of real
+// multi-hundred-megabyte tarballs.
+func withShrunkTarLimits(t *testing.T, perFile int64, perArchive int64) (restore func()) {
+	t.Helper()
+	origFile, origArchive := maxTarFileSize, maxTarArchiveSize
+	maxTarFileSize, maxTarArchiveSize = perFile, perArchive
+	return func() {
+		maxTarFileSize, maxTarArchiveSize = origFile, origArchive
+	}
+}
+
+func TestUntarRejectsOversizedFile(t *testing.T) {
+	cleanup := withTempWorkdir(t)
+	defer cleanup()
+	defer withShrunkTarLimits(t, 10, 1000)()
+
+	writeTestTarGz(t, "bomb.tar.gz", []tarEntry{
+		{name: "huge.bin", declaredSize: 11, content: []byte("short")},
+	})
+
+	if err := untar("bomb.tar.gz", false, nil); err == nil {
+		t.Fatal("expected untar to reject a file whose declared size exceeds the per-file limit")
+	}
+}
+
+func TestUntarRejectsArchiveSizeBomb(t *testing.T) {
+	cleanup := withTempWorkdir(t)
+	defer cleanup()
+	defer withShrunkTarLimits(t, 100, 250)()
+
+	entries := []tarEntry{
+		{name: "a.bin", declaredSize: 100, content: []byte("aaaaaaaaaa")},
+		{name: "b.bin", declaredSize: 100, content: []byte("bbbbbbbbbb")},
+		{name: "c.bin", declaredSize: 100, content: []byte("cccccccccc")},
+	}
+	writeTestTarGz(t, "bomb.tar.gz", entries)
+
+	if err := untar("bomb.tar.gz", false, nil); err == nil {
+		t.Fatal("expected untar to reject an archive whose declared total size exceeds the archive limit")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	survey "gopkg.in/AlecAivazis/survey.v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	newProjectName string
+	newTargetDir   string
+	newForce       bool
+)
+
+// newCmd represents the new command
+var newCmd = &cobra.Command{
+	Use:   "new [stack]",
+	Short: "Create a new appsody project from a stack, picking interactively if needed",
+	Long: `Creates a new appsody project in a target directory, prompting for anything that
+wasn't supplied on the command line. If [stack] is omitted, this command fetches the
+repository index and presents an interactive list of the available stacks to choose from.
+
+This is the easiest way to get started with appsody - it does not require knowing a
+stack name or project name up front, unlike 'appsody init'.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var index RepoIndex
+
+		err := CheckPrereqs()
+		if err != nil {
+			Warning.logf("Failed to check prerequisites: %v\n", err)
+		}
+
+		index.getIndex()
+
+		stack := ""
+		if len(args) > 0 {
+			stack = args[0]
+		}
+
+		if stack == "" {
+			stack, err = pickStack(&index)
+			if err != nil {
+				Error.log(err)
+				os.Exit(1)
+			}
+		} else if len(index.Projects[stack]) < 1 {
+			Error.logf("Could not find a stack with the name %s. Run `appsody list` to see the available stacks or -h for help.", stack)
+			os.Exit(1)
+		}
+
+		if !cmd.Flags().Changed("dir") {
+			newTargetDir, err = promptString("Target directory", newTargetDir)
+			if err != nil {
+				Error.log(err)
+				os.Exit(1)
+			}
+		}
+
+		name := newProjectName
+		if name == "" {
+			name, err = promptString("Project name", filepath.Base(newTargetDir))
+			if err != nil {
+				Error.log(err)
+				os.Exit(1)
+			}
+		}
+
+		description := ""
+		err = survey.AskOne(&survey.Input{Message: "Project description"}, &description, nil)
+		if err != nil {
+			Error.log(err)
+			os.Exit(1)
+		}
+
+		if err := createAndEnterProjectDir(newTargetDir, newForce); err != nil {
+			Error.log(err)
+			os.Exit(1)
+		}
+
+		// --force means the same thing to initProject/isFileLaydownSafe that
+		// --overwrite means to `appsody init`: proceed even though the
+		// target directory already has non-template files in it.
+		if newForce {
+			overwrite = true
+		}
+
+		Info.logf("Creating project %s from stack %s in %s", name, stack, newTargetDir)
+
+		if err := initProject(&index, stack); err != nil {
+			Error.log(err)
+			os.Exit(1)
+		}
+
+		if err := writeProjectMetadata(name, description); err != nil {
+			Warning.log("Unable to write project name/description to .appsody-config.yaml: ", err)
+		}
+
+		install()
+	},
+}
+
+// writeProjectMetadata records the interactively-prompted project name and
+// description in the generated .appsody-config.yaml, alongside the stack
+// field that untar/copyTemplateTree already populated from the template.
+func writeProjectMetadata(name string, description string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	configFile := filepath.Join(dir, ".appsody-config.yaml")
+
+	raw, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &config); err != nil {
+		return err
+	}
+
+	if name != "" {
+		config["name"] = name
+	}
+	if description != "" {
+		config["description"] = description
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(configFile, out, 0644)
+}
+
+// pickStack lists the stacks in index and prompts the user to choose one.
+func pickStack(index *RepoIndex) (string, error) {
+	var ids []string
+	labels := map[string]string{}
+	for id, entries := range index.Projects {
+		if len(entries) < 1 {
+			continue
+		}
+		ids = append(ids, id)
+		labels[id] = fmt.Sprintf("%s (%s) - %s", id, entries[0].Version, entries[0].Description)
+	}
+	sort.Strings(ids)
+
+	var options []string
+	for _, id := range ids {
+		options = append(options, labels[id])
+	}
+
+	choice := ""
+	prompt := &survey.Select{
+		Message: "Choose a stack:",
+		Options: options,
+	}
+	if err := survey.AskOne(prompt, &choice, nil); err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		if labels[id] == choice {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no stack selected")
+}
+
+func promptString(message string, defaultValue string) (string, error) {
+	value := ""
+	prompt := &survey.Input{Message: message, Default: defaultValue}
+	err := survey.AskOne(prompt, &value, nil)
+	return value, err
+}
+
+// createAndEnterProjectDir creates dir (if necessary), requiring it to be empty
+// unless force is set, and chdirs the process into it.
+func createAndEnterProjectDir(dir string, force bool) error {
+	if dir == "" {
+		return fmt.Errorf("no target directory specified")
+	}
+
+	if info, err := os.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s exists and is not a directory", dir)
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 && !force {
+			return fmt.Errorf("%s is not empty. Use --force to reuse it", dir)
+		}
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.Chdir(dir)
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	newCmd.PersistentFlags().StringVar(&newProjectName, "name", "", "The name of the new project.")
+	newCmd.PersistentFlags().StringVar(&newTargetDir, "dir", ".", "The target directory to create the project in.")
+	newCmd.PersistentFlags().BoolVar(&newForce, "force", false, "Proceed even if the target directory is not empty.")
+}
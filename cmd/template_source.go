@@ -0,0 +1,300 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Downloader materializes a template project, identified by url, into the
+// current directory. TarDownloader and GitDownloader are the two built-in
+// implementations, selected by DownloaderForSource based on the URL scheme -
+// analogous to s2i's DownloaderForSource.
+type Downloader interface {
+	Fetch(index *RepoIndex, projectType string, url string) error
+}
+
+// DownloaderForSource picks the Downloader implementation appropriate for
+// url: GitDownloader for git://, git+https://, git+ssh://, ssh://...git, or any URL
+// ending in .git (optionally followed by a #ref fragment), TarDownloader
+// otherwise.
+func DownloaderForSource(url string) Downloader {
+	if isGitSource(url) {
+		return &GitDownloader{}
+	}
+	return &TarDownloader{}
+}
+
+func isGitSource(url string) bool {
+	repoURL, _ := splitGitRef(url)
+	switch {
+	case strings.HasPrefix(repoURL, "git://"),
+		strings.HasPrefix(repoURL, "git+https://"),
+		strings.HasPrefix(repoURL, "git+ssh://"):
+		return true
+	case strings.HasPrefix(repoURL, "ssh://") && strings.HasSuffix(repoURL, ".git"):
+		return true
+	case strings.HasSuffix(repoURL, ".git"):
+		return true
+	}
+	return false
+}
+
+// splitGitRef splits a template URL of the form <repo>#<ref> into the
+// repository URL and the branch/tag/commit to check out. ref is "" when no
+// fragment is present, meaning the repository's default branch.
+func splitGitRef(url string) (repoURL string, ref string) {
+	if idx := strings.LastIndex(url, "#"); idx != -1 {
+		return url[:idx], url[idx+1:]
+	}
+	return url, ""
+}
+
+// commitSHAPattern matches a (possibly abbreviated) git commit SHA - as
+// opposed to a branch or tag name, which git's --branch clone option
+// requires.
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+func looksLikeCommitSHA(ref string) bool {
+	return commitSHAPattern.MatchString(ref)
+}
+
+func runGitCmd(args ...string) error {
+	Debug.log("Running git ", strings.Join(args, " "))
+	gitCmd := exec.Command("git", args...)
+	gitCmd.Stdout = os.Stdout
+	gitCmd.Stderr = os.Stderr
+	return gitCmd.Run()
+}
+
+// TarDownloader fetches a tar.gz template over HTTP(S)/file, verifies it,
+// and extracts it with untar. This is the long-standing appsody init
+// behavior.
+type TarDownloader struct{}
+
+func (d *TarDownloader) Fetch(index *RepoIndex, projectType string, url string) error {
+	Info.logf("Downloading %s template project from %s", projectType, url)
+	filename := projectType + ".tar.gz"
+
+	if err := downloadFile(url, filename); err != nil {
+		return fmt.Errorf("Error downloading tar %v", err)
+	}
+
+	if !dryrun {
+		if err := verifyTemplate(index, projectType, filename); err != nil {
+			return err
+		}
+	}
+
+	Info.log("Download complete. Extracting files from ", filename)
+
+	exclude, err := compileExcludePattern(excludePattern)
+	if err != nil {
+		return fmt.Errorf("Invalid --exclude pattern: %v", err)
+	}
+
+	errUntar := untar(filename, noTemplate, exclude)
+
+	if dryrun {
+		Info.logf("Dry Run - Skipping remove of temporary file for project type: %s project name: %s", projectType, url)
+	} else {
+		if err := os.Remove(filename); err != nil {
+			Warning.log("Unable to remove temporary file ", filename)
+		}
+		Info.log("Successfully initialized ", projectType, " project")
+	}
+
+	if errUntar != nil {
+		return fmt.Errorf("Error extracting template: %v", errUntar)
+	}
+	return nil
+}
+
+// GitDownloader clones a template directly from a Git repository, rather
+// than downloading a release tarball, so stack authors can iterate on
+// templates without cutting a release for every change.
+type GitDownloader struct{}
+
+func (d *GitDownloader) Fetch(index *RepoIndex, projectType string, url string) error {
+	repoURL, ref := splitGitRef(url)
+
+	if dryrun {
+		Info.logf("Dry Run - Skipping git clone of %s (ref %s)", repoURL, ref)
+		return nil
+	}
+
+	Info.logf("Cloning %s template project from %s", projectType, repoURL)
+
+	cloneDir := ".appsody-template-clone"
+	if err := os.RemoveAll(cloneDir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(cloneDir)
+
+	// --branch only resolves a branch or tag name on essentially all git
+	// servers - it can't check out an arbitrary commit SHA. So when ref
+	// looks like a commit, do a full clone and `git checkout` it instead
+	// of a shallow, branch-pinned one.
+	pinnedToCommit := ref != "" && looksLikeCommitSHA(ref)
+
+	cloneArgs := []string{"clone"}
+	if !pinnedToCommit {
+		cloneArgs = append(cloneArgs, "--depth", "1")
+		if ref != "" {
+			cloneArgs = append(cloneArgs, "--branch", ref)
+		}
+	}
+	cloneArgs = append(cloneArgs, repoURL, cloneDir)
+
+	if err := runGitCmd(cloneArgs...); err != nil {
+		return fmt.Errorf("Error cloning %s: %v", repoURL, err)
+	}
+
+	if pinnedToCommit {
+		if err := runGitCmd("-C", cloneDir, "checkout", ref); err != nil {
+			return fmt.Errorf("Error checking out %s in %s: %v", ref, repoURL, err)
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(cloneDir, ".git")); err != nil {
+		Warning.log("Unable to remove .git directory from cloned template ", err)
+	}
+
+	exclude, err := compileExcludePattern(excludePattern)
+	if err != nil {
+		return fmt.Errorf("Invalid --exclude pattern: %v", err)
+	}
+
+	if !overwrite && !noTemplate {
+		if err := preCheckGitTree(cloneDir, exclude); err != nil {
+			return err
+		}
+	}
+
+	return copyTemplateTree(cloneDir, ".", noTemplate, exclude)
+}
+
+// preCheckGitTree walks the cloned template in srcDir and reports a conflict
+// error if any whitelisted file it would copy already exists in the current
+// directory - the same conflict detection preCheckTar does for a tar.gz
+// template, so a git-sourced template is no more willing to clobber existing
+// files than a tarball one without --overwrite.
+func preCheckGitTree(srcDir string, exclude *regexp.Regexp) error {
+	preCheckOK := true
+
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if exclude != nil && exclude.MatchString(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if inWhiteList(rel) {
+			if fileInfo, err := os.Stat(rel); err == nil && !fileInfo.IsDir() {
+				preCheckOK = false
+				Warning.log("Conflict: " + rel + " exists in the file system and the template project.")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !preCheckOK {
+		return errors.New("conflicts exist. If you wish to proceed, try again with the --overwrite option")
+	}
+	return nil
+}
+
+// copyTemplateTree copies the template files cloned into srcDir into
+// destDir, honoring the same noTemplate/exclude semantics as untar so
+// `appsody init --no-template` and `--exclude` behave the same regardless
+// of whether the template came from a tarball or a Git repository.
+func copyTemplateTree(srcDir string, destDir string, noTemplate bool, exclude *regexp.Regexp) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if exclude != nil && exclude.MatchString(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target, err := safeExtractPath(destDir, rel)
+		if err != nil {
+			return fmt.Errorf("refusing to copy %s: %v", rel, err)
+		}
+
+		if info.IsDir() {
+			if !noTemplate {
+				return os.MkdirAll(target, 0755)
+			}
+			return nil
+		}
+
+		if !noTemplate || strings.HasSuffix(target, ".appsody-config.yaml") {
+			return copyFile(path, target, info.Mode())
+		}
+		return nil
+	})
+}
+
+func copyFile(src string, dest string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_RDWR|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
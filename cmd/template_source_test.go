@@ -0,0 +1,89 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import "testing"
+
+func TestSplitGitRef(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantRepo string
+		wantRef  string
+	}{
+		{"https://github.com/appsody/stacks.git", "https://github.com/appsody/stacks.git", ""},
+		{"https://github.com/appsody/stacks.git#main", "https://github.com/appsody/stacks.git", "main"},
+		{"git://github.com/appsody/stacks#v1.2.3", "git://github.com/appsody/stacks", "v1.2.3"},
+		{"ssh://git@github.com/appsody/stacks.git#0123abcd0123abcd0123abcd0123abcd01234567", "ssh://git@github.com/appsody/stacks.git", "0123abcd0123abcd0123abcd0123abcd01234567"},
+	}
+
+	for _, tc := range tests {
+		repo, ref := splitGitRef(tc.url)
+		if repo != tc.wantRepo || ref != tc.wantRef {
+			t.Errorf("splitGitRef(%q) = (%q, %q), want (%q, %q)", tc.url, repo, ref, tc.wantRepo, tc.wantRef)
+		}
+	}
+}
+
+func TestIsGitSource(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://github.com/appsody/stacks.git", true},
+		{"https://github.com/appsody/stacks.git#main", true},
+		{"git://github.com/appsody/stacks", true},
+		{"git+https://example.com/repo", true},
+		{"git+ssh://example.com/repo", true},
+		{"ssh://git@example.com/repo.git", true},
+		{"ssh://git@example.com/repo", false},
+		{"https://example.com/stacks/nodejs-express.tar.gz", false},
+		{"file:///tmp/nodejs-express.tar.gz", false},
+	}
+
+	for _, tc := range tests {
+		if got := isGitSource(tc.url); got != tc.want {
+			t.Errorf("isGitSource(%q) = %v, want %v", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestDownloaderForSource(t *testing.T) {
+	if _, ok := DownloaderForSource("https://example.com/stacks/nodejs-express.tar.gz").(*TarDownloader); !ok {
+		t.Error("expected a tar.gz URL to select TarDownloader")
+	}
+	if _, ok := DownloaderForSource("https://github.com/appsody/stacks.git#main").(*GitDownloader); !ok {
+		t.Error("expected a .git URL to select GitDownloader")
+	}
+}
+
+func TestLooksLikeCommitSHA(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"main", false},
+		{"v1.2.3", false},
+		{"release-1.0", false},
+		{"abc123d", true},
+		{"0123abcd0123abcd0123abcd0123abcd01234567", true},
+		{"not-a-sha!", false},
+	}
+
+	for _, tc := range tests {
+		if got := looksLikeCommitSHA(tc.ref); got != tc.want {
+			t.Errorf("looksLikeCommitSHA(%q) = %v, want %v", tc.ref, got, tc.want)
+		}
+	}
+}
